@@ -0,0 +1,280 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	hierr "github.com/reconquest/hierr-go"
+)
+
+// gkSketch is a Greenwald-Khanna streaming quantile summary: it keeps a
+// bounded number of samples, each annotated with how many values could
+// fall within its rank error, so a fingerprint's memory footprint stays
+// roughly O(1/epsilon) no matter how many times that query appears in a
+// multi-GB log.
+type gkSketch struct {
+	epsilon float64
+	n       int
+	samples []gkSample
+}
+
+type gkSample struct {
+	value float64
+	g     int
+	delta int
+}
+
+func newGKSketch(epsilon float64) *gkSketch {
+	return &gkSketch{epsilon: epsilon}
+}
+
+// insert records a new value in the sketch, compressing once enough
+// values have been seen to bound its size to roughly 1/epsilon samples.
+func (s *gkSketch) insert(value float64) {
+	s.n++
+
+	i := sort.Search(len(s.samples), func(i int) bool {
+		return s.samples[i].value >= value
+	})
+
+	delta := int(math.Floor(2 * s.epsilon * float64(s.n)))
+	if i == 0 || i == len(s.samples) {
+		delta = 0
+	}
+
+	s.samples = append(s.samples, gkSample{})
+	copy(s.samples[i+1:], s.samples[i:])
+	s.samples[i] = gkSample{value: value, g: 1, delta: delta}
+
+	if compressEvery := int(1 / (2 * s.epsilon)); compressEvery > 0 && s.n%compressEvery == 0 {
+		s.compress()
+	}
+}
+
+// compress drops samples that can be reconstructed within epsilon from
+// their neighbours. A sample at i can be deleted, folding its g into
+// its right neighbour, when the merged band (g[i]+g[i+1]+delta[i+1])
+// still fits the capacity bound -- the check must use the band the
+// right neighbour ends up with, or deletions creep past the epsilon
+// bound as the sketch grows.
+func (s *gkSketch) compress() {
+	threshold := int(math.Floor(2 * s.epsilon * float64(s.n)))
+
+	for i := len(s.samples) - 2; i >= 1; i-- {
+		if s.samples[i].g+s.samples[i+1].g+s.samples[i+1].delta <= threshold {
+			s.samples[i+1].g += s.samples[i].g
+			s.samples = append(s.samples[:i], s.samples[i+1:]...)
+		}
+	}
+}
+
+// quantile returns the approximate value at quantile q (0..1), within
+// epsilon of the true rank.
+func (s *gkSketch) quantile(q float64) float64 {
+	if len(s.samples) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(q * float64(s.n)))
+	threshold := int(math.Floor(s.epsilon * float64(s.n)))
+
+	cumulative := 0
+	for i, sample := range s.samples {
+		cumulative += sample.g
+		if cumulative+sample.delta > rank+threshold {
+			if i == 0 {
+				return sample.value
+			}
+
+			return s.samples[i-1].value
+		}
+	}
+
+	return s.samples[len(s.samples)-1].value
+}
+
+// gkEpsilon bounds every sketch's rank error to 1%, which keeps its size
+// around a hundred samples regardless of how many times a query
+// fingerprint recurs in the log.
+const gkEpsilon = 0.01
+
+// fingerprintSummary accumulates --aggregate statistics for a single
+// query fingerprint as matching records stream in.
+type fingerprintSummary struct {
+	fingerprint  string
+	digest       string
+	exampleQuery string
+	count        int64
+
+	queryTimeSum, queryTimeMin, queryTimeMax float64
+	hasQueryTime                             bool
+	queryTimeSketch                          *gkSketch
+
+	lockTimeSum, lockTimeMin, lockTimeMax float64
+	hasLockTime                           bool
+	lockTimeSketch                        *gkSketch
+
+	rowsExaminedSum, rowsSentSum int64
+
+	firstTime, lastTime time.Time
+	tables              map[string]bool
+}
+
+func newFingerprintSummary(record Record) *fingerprintSummary {
+	summary := &fingerprintSummary{
+		fingerprint:     asString(record["fingerprintID"]),
+		digest:          asString(record["query_digest"]),
+		exampleQuery:    asString(record["query"]),
+		queryTimeSketch: newGKSketch(gkEpsilon),
+		lockTimeSketch:  newGKSketch(gkEpsilon),
+		tables:          map[string]bool{},
+	}
+
+	summary.queryTimeMin = math.Inf(1)
+	summary.lockTimeMin = math.Inf(1)
+
+	return summary
+}
+
+func asString(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+// add folds one processed record (as returned by process(), before
+// prepare() stringifies its time.Time/time.Duration fields) into the
+// summary.
+func (summary *fingerprintSummary) add(record Record) {
+	summary.count++
+
+	if queryTime, ok := record["query_time"].(time.Duration); ok {
+		seconds := queryTime.Seconds()
+		summary.queryTimeSum += seconds
+		summary.queryTimeMin = math.Min(summary.queryTimeMin, seconds)
+		summary.queryTimeMax = math.Max(summary.queryTimeMax, seconds)
+		summary.queryTimeSketch.insert(seconds)
+		summary.hasQueryTime = true
+	}
+
+	if lockTime, ok := record["lock_time"].(time.Duration); ok {
+		seconds := lockTime.Seconds()
+		summary.lockTimeSum += seconds
+		summary.lockTimeMin = math.Min(summary.lockTimeMin, seconds)
+		summary.lockTimeMax = math.Max(summary.lockTimeMax, seconds)
+		summary.lockTimeSketch.insert(seconds)
+		summary.hasLockTime = true
+	}
+
+	if rowsExamined, ok := record["rows_examined"].(int64); ok {
+		summary.rowsExaminedSum += rowsExamined
+	}
+
+	if rowsSent, ok := record["rows_sent"].(int64); ok {
+		summary.rowsSentSum += rowsSent
+	}
+
+	if timeStart, ok := record["time_start"].(time.Time); ok {
+		if summary.firstTime.IsZero() || timeStart.Before(summary.firstTime) {
+			summary.firstTime = timeStart
+		}
+
+		if timeStart.After(summary.lastTime) {
+			summary.lastTime = timeStart
+		}
+	}
+
+	for _, table := range strings.Split(asString(record["table"]), ",") {
+		if table != "" {
+			summary.tables[table] = true
+		}
+	}
+}
+
+func (summary *fingerprintSummary) toRecord() Record {
+	tables := make([]string, 0, len(summary.tables))
+	for table := range summary.tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	queryTimeMin := summary.queryTimeMin
+	if !summary.hasQueryTime {
+		queryTimeMin = 0
+	}
+
+	lockTimeMin := summary.lockTimeMin
+	if !summary.hasLockTime {
+		lockTimeMin = 0
+	}
+
+	return Record{
+		"fingerprintID": summary.fingerprint,
+		"query_digest":  summary.digest,
+		"example_query": summary.exampleQuery,
+		"count":         summary.count,
+
+		"query_time_sum": summary.queryTimeSum,
+		"query_time_min": queryTimeMin,
+		"query_time_max": summary.queryTimeMax,
+		"query_time_p50": summary.queryTimeSketch.quantile(0.50),
+		"query_time_p95": summary.queryTimeSketch.quantile(0.95),
+		"query_time_p99": summary.queryTimeSketch.quantile(0.99),
+
+		"lock_time_sum": summary.lockTimeSum,
+		"lock_time_min": lockTimeMin,
+		"lock_time_max": summary.lockTimeMax,
+		"lock_time_p50": summary.lockTimeSketch.quantile(0.50),
+		"lock_time_p95": summary.lockTimeSketch.quantile(0.95),
+		"lock_time_p99": summary.lockTimeSketch.quantile(0.99),
+
+		"rows_examined_sum": summary.rowsExaminedSum,
+		"rows_sent_sum":     summary.rowsSentSum,
+
+		"time_first": summary.firstTime.Format(timeOutputLayout),
+		"time_last":  summary.lastTime.Format(timeOutputLayout),
+		"tables":     tables,
+	}
+}
+
+// aggregator implements --aggregate: it keeps one fingerprintSummary per
+// fingerprintID instead of emitting a record per query, and emits the
+// summaries through the configured Emitter on flush.
+type aggregator struct {
+	summaries map[string]*fingerprintSummary
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{summaries: map[string]*fingerprintSummary{}}
+}
+
+func (a *aggregator) add(record Record) {
+	fingerprint := asString(record["fingerprintID"])
+
+	summary, ok := a.summaries[fingerprint]
+	if !ok {
+		summary = newFingerprintSummary(record)
+		a.summaries[fingerprint] = summary
+	}
+
+	summary.add(record)
+}
+
+// flush emits one record per fingerprint, sorted by fingerprintID for
+// stable output, and resets the aggregator.
+func (a *aggregator) flush() {
+	fingerprints := make([]string, 0, len(a.summaries))
+	for fingerprint := range a.summaries {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+
+	for _, fingerprint := range fingerprints {
+		if err := emitter.Emit(a.summaries[fingerprint].toRecord()); err != nil {
+			hierr.Fatalf(err, "can't emit fingerprint summary: %s", fingerprint)
+		}
+	}
+
+	a.summaries = map[string]*fingerprintSummary{}
+}
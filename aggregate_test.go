@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestGKSketchEpsilonBound checks that quantile() stays within the
+// configured epsilon of the true rank across a range of epsilons, since
+// compress() previously let deletions creep past the advertised bound.
+func TestGKSketchEpsilonBound(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, epsilon := range []float64{0.01, 0.02, 0.05} {
+		sketch := newGKSketch(epsilon)
+
+		const n = 20000
+		values := make([]float64, n)
+		for i := range values {
+			values[i] = rng.Float64()
+			sketch.insert(values[i])
+		}
+
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+
+		for _, q := range []float64{0.5, 0.9, 0.95, 0.99} {
+			estimate := sketch.quantile(q)
+
+			trueRank := sort.SearchFloat64s(sorted, estimate)
+			wantRank := int(math.Ceil(q * n))
+
+			if err := math.Abs(float64(trueRank-wantRank)) / n; err > epsilon {
+				t.Errorf(
+					"epsilon=%v q=%v: rank error %v exceeds bound",
+					epsilon, q, err,
+				)
+			}
+		}
+	}
+}
+
+// TestFingerprintSummaryMissingLockTime guards against fingerprints
+// whose records never carry lock_time: toRecord() used to leave
+// lock_time_min at +Inf, which json.Marshal rejects.
+func TestFingerprintSummaryMissingLockTime(t *testing.T) {
+	record := Record{
+		"fingerprintID": "abc",
+		"query_time":    5 * time.Millisecond,
+	}
+
+	summary := newFingerprintSummary(record)
+	summary.add(record)
+
+	out := summary.toRecord()
+
+	if _, err := json.Marshal(out); err != nil {
+		t.Fatalf("toRecord() produced a value json can't marshal: %s", err)
+	}
+
+	if out["lock_time_min"] != 0.0 {
+		t.Errorf("lock_time_min = %v, want 0 when no record carried lock_time", out["lock_time_min"])
+	}
+}
+
+// TestAggregateCSVColumnsMatchSummary makes sure the --aggregate csv
+// column list is kept in sync with fingerprintSummary.toRecord(): a
+// column with no matching key would silently emit an empty cell.
+func TestAggregateCSVColumnsMatchSummary(t *testing.T) {
+	record := Record{
+		"fingerprintID": "abc",
+		"query_time":    5 * time.Millisecond,
+		"lock_time":     time.Millisecond,
+		"time_start":    time.Now(),
+	}
+
+	summary := newFingerprintSummary(record)
+	summary.add(record)
+	out := summary.toRecord()
+
+	for _, column := range aggregateCSVColumns() {
+		if _, ok := out[column]; !ok {
+			t.Errorf("aggregateCSVColumns() has %q, missing from toRecord()", column)
+		}
+	}
+
+	for key := range out {
+		found := false
+		for _, column := range aggregateCSVColumns() {
+			if column == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("toRecord() has %q, missing from aggregateCSVColumns()", key)
+		}
+	}
+}
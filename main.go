@@ -2,15 +2,20 @@ package main
 
 import (
 	"bufio"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"os/signal"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"os"
@@ -37,12 +42,92 @@ Usage:
     poke --version
 
 Options:
-    -f --file <path>  Specify file location to read.
-    -h --help         Show this screen.
-    --version         Show version.
+    -f --file <path>            Specify file location to read. Required
+                                 by follow.
+    --follow                    Keep the file open after EOF and keep
+                                 reading appended data, like tail -f.
+                                 Detects log rotation and reopens the
+                                 path.
+    --poll-interval <duration>  Interval to poll for new data when
+                                 follow is given. [default: 1s]
+    --output <sink>             Output sink: ndjson, csv, esbulk, or
+                                 tcp://host:port. [default: ndjson]
+    --es-index <name>           Index name used by the esbulk sink; the
+                                 record's time_start date is appended as
+                                 a suffix (name-YYYY.MM.DD).
+                                 [default: poke]
+    --advise                    Attach an "advice" array of heuristic
+                                 query-review findings to each record.
+    --advise-rules <ids>        Comma-separated rule IDs to restrict
+                                 advise to, e.g. SEL.001,UPD.002. Empty
+                                 means all rules.
+    --advise-in-threshold <n>   IN (...) list length above which rule
+                                 SEL.007 fires. [default: 100]
+    --timezone <tz>             Location used to interpret Time: values
+                                 whose layout has no zone offset: UTC,
+                                 Local, or an IANA name. [default: UTC]
+    --time-layout <layout>      Go reference-time layout to parse Time:
+                                 with, overriding the built-in layout
+                                 list.
+    --aggregate                 Instead of one record per query, buffer
+                                 records by fingerprintID and emit one
+                                 summary per fingerprint once the input
+                                 is exhausted.
+    --default-schema <name>     Schema to prefix schema_meta table names
+                                 with when a query doesn't qualify them.
+                                 The table field is left unprefixed.
+    -h --help                   Show this screen.
+    --version                   Show version.
 `
 )
 
+// timeOutputLayout is the layout prepare formats time.Time fields with
+// before a record is handed to an Emitter. It keeps the parsed offset
+// instead of forcing UTC, so time_start reflects the server's
+// log_timestamps setting.
+const timeOutputLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+var (
+	// timeLocation is used to interpret datetime fields whose layout
+	// doesn't carry its own zone offset. Overridden by --timezone.
+	timeLocation = time.UTC
+
+	// timeLayouts are tried in order against the Time: field. The first
+	// layout that matches wins.
+	timeLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.000000Z",
+		"2006-01-02T15:04:05.000000",
+		"060102 15:04:05",
+	}
+
+	// timeLayoutOverride, when set via --time-layout, replaces
+	// timeLayouts entirely.
+	timeLayoutOverride string
+)
+
+// parseDatetime parses the Time: field against timeLayouts (or
+// timeLayoutOverride, if set), trying each layout in order so both
+// RFC3339-style and older MySQL slow-log timestamps are accepted.
+func parseDatetime(raw string) (time.Time, error) {
+	layouts := timeLayouts
+	if timeLayoutOverride != "" {
+		layouts = []string{timeLayoutOverride}
+	}
+
+	var lastErr error
+	for _, layout := range layouts {
+		parsed, err := time.ParseInLocation(layout, raw, timeLocation)
+		if err == nil {
+			return parsed, nil
+		}
+
+		lastErr = err
+	}
+
+	return time.Time{}, lastErr
+}
+
 var (
 	rules = map[string]string{
 		"Time":                  `datetime`,
@@ -107,8 +192,86 @@ func main() {
 	args := godocs.MustParse(usage, version, godocs.UsePager)
 
 	compileRegexps()
-	inputReader := os.Stdin
+
+	follow, _ := args["--follow"].(bool)
 	filename, ok := args["--file"].(string)
+	if follow && (!ok || filename == "") {
+		hierr.Fatalf(
+			errors.New("--file is required"),
+			"--follow requires --file to be specified",
+		)
+	}
+
+	pollIntervalRaw, _ := args["--poll-interval"].(string)
+	pollInterval, err := time.ParseDuration(pollIntervalRaw)
+	if err != nil {
+		hierr.Fatalf(err, "invalid --poll-interval: %s", pollIntervalRaw)
+	}
+
+	aggregateEnabled, _ := args["--aggregate"].(bool)
+
+	output, _ := args["--output"].(string)
+	esIndex, _ := args["--es-index"].(string)
+	emitter, err = newEmitter(output, esIndex, aggregateEnabled)
+	if err != nil {
+		hierr.Fatalf(err, "can't initialize --output sink: %s", output)
+	}
+
+	adviseEnabled, _ = args["--advise"].(bool)
+	if raw, ok := args["--advise-rules"].(string); ok && raw != "" {
+		adviseRules = map[string]bool{}
+		for _, id := range strings.Split(raw, ",") {
+			adviseRules[strings.TrimSpace(id)] = true
+		}
+	}
+
+	if raw, ok := args["--advise-in-threshold"].(string); ok && raw != "" {
+		adviseInThreshold, err = strconv.Atoi(raw)
+		if err != nil {
+			hierr.Fatalf(err, "invalid --advise-in-threshold: %s", raw)
+		}
+	}
+
+	timezone, _ := args["--timezone"].(string)
+	switch timezone {
+	case "", "UTC":
+		timeLocation = time.UTC
+	case "Local":
+		timeLocation = time.Local
+	default:
+		timeLocation, err = time.LoadLocation(timezone)
+		if err != nil {
+			hierr.Fatalf(err, "invalid --timezone: %s", timezone)
+		}
+	}
+
+	timeLayoutOverride, _ = args["--time-layout"].(string)
+
+	defaultSchema, _ = args["--default-schema"].(string)
+
+	var fingerprints *aggregator
+	if aggregateEnabled {
+		fingerprints = newAggregator()
+	}
+
+	consume := func(record Record) {
+		flushed, ok := process(record)
+		if !ok {
+			return
+		}
+
+		if fingerprints != nil {
+			fingerprints.add(flushed)
+			return
+		}
+
+		prepare(flushed)
+	}
+
+	var (
+		inputReader io.Reader = os.Stdin
+		tail        *tailer
+	)
 	if ok && filename != "" {
 		file, err := os.Open(filename)
 		if err != nil {
@@ -117,18 +280,62 @@ func main() {
 			)
 		}
 		inputReader = file
+
+		if follow {
+			tail = &tailer{path: filename, file: file}
+		}
 	}
+
 	var (
+		mutex  sync.Mutex
 		reader = bufio.NewReader(inputReader)
 		record = Record{}
-		// records = []Record{}
 	)
 
+	flush := func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if len(record) > 0 {
+			consume(record)
+			record = Record{}
+		}
+
+		if fingerprints != nil {
+			fingerprints.flush()
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-signals
+		flush()
+		os.Exit(0)
+	}()
+
 	var line string
 	for {
 		data, isPrefix, err := reader.ReadLine()
 		if err != nil {
 			if err == io.EOF {
+				if tail != nil {
+					time.Sleep(pollInterval)
+
+					reopened, rerr := tail.reopenIfRotated()
+					if rerr != nil {
+						hierr.Fatalf(
+							rerr, "can't check for log rotation: %s", filename,
+						)
+					}
+
+					if reopened != nil {
+						reader = reopened
+					}
+
+					continue
+				}
+
 				break
 			}
 
@@ -144,12 +351,11 @@ func main() {
 
 		line = string(data)
 
+		mutex.Lock()
+
 		if strings.HasPrefix(line, "# Time: ") {
 			if len(record) > 0 {
-				if record, ok := process(record); ok {
-					record = prepare(record)
-					// records = append(records, record)
-				}
+				consume(record)
 			}
 
 			record = Record{}
@@ -157,28 +363,63 @@ func main() {
 
 		if !strings.HasPrefix(line, "#") {
 			if "" == getQueryType(line) {
+				mutex.Unlock()
 				continue
 			}
 		}
 
 		err = unmarshal(line, record)
 		if err != nil {
+			mutex.Unlock()
 			hierr.Fatalf(err, "unmarshal error")
 		}
+
+		mutex.Unlock()
 	}
 
-	if record, ok := process(record); ok {
-		record = prepare(record)
-		// records = append(records, record)
+	flush()
+}
+
+// tailer keeps a slow log file open across polls of a --follow run,
+// re-opening the path when it detects the file has been rotated (renamed
+// away and replaced, or truncated in place by the log server).
+type tailer struct {
+	path string
+	file *os.File
+}
+
+// reopenIfRotated checks whether the file at t.path was rotated since it
+// was last opened and, if so, reopens it and returns a reader positioned
+// at its start. It returns a nil reader when no rotation was detected.
+func (t *tailer) reopenIfRotated() (*bufio.Reader, error) {
+	pathInfo, err := os.Stat(t.path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := t.file.Stat()
+	if err != nil {
+		return nil, err
 	}
 
-	// data, err := json.MarshalIndent(records, "", "  ")
-	// if err != nil {
-	// 	hierr.Fatalf(
-	// 		err, "unable to encode records to JSON",
-	// 	)
-	// }
-	// fmt.Println(string(data))
+	offset, err := t.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	if os.SameFile(pathInfo, fileInfo) && pathInfo.Size() >= offset {
+		return nil, nil
+	}
+
+	file, err := os.Open(t.path)
+	if err != nil {
+		return nil, err
+	}
+
+	t.file.Close()
+	t.file = file
+
+	return bufio.NewReader(file), nil
 }
 
 func process(record Record) (Record, bool) {
@@ -225,6 +466,11 @@ func process(record Record) (Record, bool) {
 			return record, false
 		}
 		record["table"] = tableName
+		record["schema_meta"] = buildSchemaMeta(stmt)
+
+		if adviseEnabled {
+			record["advice"] = advise(stmt)
+		}
 	}
 
 	return record, true
@@ -234,21 +480,238 @@ func prepare(record Record) Record {
 	for key, value := range record {
 		switch value := value.(type) {
 		case time.Time:
-			record[key] = value.Format("2006-01-02T15:04:05.000000Z")
+			record[key] = value.Format(timeOutputLayout)
 
 		case time.Duration:
 			record[key] = value.Seconds()
 		}
 	}
 
-	if output, err := json.Marshal(record); err != nil {
-		hierr.Fatalf(err, "Ouput Marshal error %v", record)
-	} else {
-		fmt.Println(string(output))
+	if err := emitter.Emit(record); err != nil {
+		hierr.Fatalf(err, "can't emit record: %v", record)
 	}
+
 	return record
 }
 
+// emitter is the output sink prepare() hands finished records to. It
+// defaults to NDJSON on stdout and is replaced in main() according to
+// the --output flag.
+var emitter Emitter = NewNDJSONEmitter(os.Stdout)
+
+// Emitter writes a single prepared record to an output sink.
+type Emitter interface {
+	Emit(record Record) error
+}
+
+// NDJSONEmitter writes one JSON object per line, poke's original output
+// format and the default expected by most log shippers.
+type NDJSONEmitter struct {
+	writer io.Writer
+}
+
+func NewNDJSONEmitter(writer io.Writer) *NDJSONEmitter {
+	return &NDJSONEmitter{writer: writer}
+}
+
+func (emitter *NDJSONEmitter) Emit(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(emitter.writer, string(data))
+	return err
+}
+
+// CSVEmitter writes records as CSV rows using a column order derived from
+// the known slow-log fields in `rules`, so the header stays stable across
+// records regardless of which fields a given query populated.
+type CSVEmitter struct {
+	writer  *csv.Writer
+	columns []string
+	started bool
+}
+
+func NewCSVEmitter(writer io.Writer) *CSVEmitter {
+	return NewCSVEmitterWithColumns(writer, csvColumns())
+}
+
+// NewCSVEmitterWithColumns is NewCSVEmitter with an explicit column set,
+// used by --aggregate --output=csv where the record schema is the
+// fingerprint summary produced by toRecord(), not the per-query schema.
+func NewCSVEmitterWithColumns(writer io.Writer, columns []string) *CSVEmitter {
+	return &CSVEmitter{
+		writer:  csv.NewWriter(writer),
+		columns: columns,
+	}
+}
+
+func csvColumns() []string {
+	columns := make([]string, 0, len(rules))
+	for key := range rules {
+		columns = append(columns, strings.ToLower(key))
+	}
+	sort.Strings(columns)
+
+	return append(
+		columns,
+		"time_start", "query", "query_length", "query_type",
+		"query_digest", "fingerprintID", "table",
+	)
+}
+
+// aggregateCSVColumns lists fingerprintSummary.toRecord()'s fields in a
+// stable order for the --aggregate --output=csv sink.
+func aggregateCSVColumns() []string {
+	return []string{
+		"fingerprintID", "query_digest", "example_query", "count",
+		"query_time_sum", "query_time_min", "query_time_max",
+		"query_time_p50", "query_time_p95", "query_time_p99",
+		"lock_time_sum", "lock_time_min", "lock_time_max",
+		"lock_time_p50", "lock_time_p95", "lock_time_p99",
+		"rows_examined_sum", "rows_sent_sum",
+		"time_first", "time_last", "tables",
+	}
+}
+
+// csvCellValue stringifies a record field for a CSV cell. []string
+// fields such as toRecord()'s tables are joined with ";" rather than
+// left in Go's bracketed slice notation; the per-query table field is a
+// plain string and is written as-is, commas included.
+func csvCellValue(value interface{}) string {
+	if tables, ok := value.([]string); ok {
+		return strings.Join(tables, ";")
+	}
+
+	return fmt.Sprint(value)
+}
+
+func (emitter *CSVEmitter) Emit(record Record) error {
+	if !emitter.started {
+		if err := emitter.writer.Write(emitter.columns); err != nil {
+			return err
+		}
+
+		emitter.started = true
+	}
+
+	row := make([]string, len(emitter.columns))
+	for i, column := range emitter.columns {
+		if value, ok := record[column]; ok {
+			row[i] = csvCellValue(value)
+		}
+	}
+
+	if err := emitter.writer.Write(row); err != nil {
+		return err
+	}
+
+	emitter.writer.Flush()
+	return emitter.writer.Error()
+}
+
+// ESBulkEmitter writes records in Elasticsearch `_bulk` format: an index
+// action line followed by the document itself, ready to be posted to
+// `/_bulk` with the ndjson content type.
+type ESBulkEmitter struct {
+	writer    io.Writer
+	indexName string
+}
+
+func NewESBulkEmitter(writer io.Writer, indexName string) *ESBulkEmitter {
+	return &ESBulkEmitter{writer: writer, indexName: indexName}
+}
+
+func (emitter *ESBulkEmitter) Emit(record Record) error {
+	index := emitter.indexName
+	if timeStart, ok := record["time_start"].(string); ok {
+		if parsed, err := time.Parse(timeOutputLayout, timeStart); err == nil {
+			index = fmt.Sprintf("%s-%s", emitter.indexName, parsed.Format("2006.01.02"))
+		}
+	}
+
+	action, err := json.Marshal(map[string]interface{}{
+		"index": map[string]interface{}{"_index": index},
+	})
+	if err != nil {
+		return err
+	}
+
+	document, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintln(emitter.writer, string(action)); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(emitter.writer, string(document))
+	return err
+}
+
+// TCPEmitter streams NDJSON records to a collector such as Logstash's
+// tcp input, dialing lazily on the first Emit. A dial or write failure
+// is returned like any other Emitter error, which every caller treats
+// as fatal, so poke exits rather than retrying; the dropped connection
+// is only relevant if a future caller chooses to keep going after an
+// Emit error, at which point the next Emit redials.
+type TCPEmitter struct {
+	addr string
+	conn net.Conn
+}
+
+func NewTCPEmitter(addr string) *TCPEmitter {
+	return &TCPEmitter{addr: addr}
+}
+
+func (emitter *TCPEmitter) Emit(record Record) error {
+	if emitter.conn == nil {
+		conn, err := net.Dial("tcp", emitter.addr)
+		if err != nil {
+			return err
+		}
+
+		emitter.conn = conn
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintln(emitter.conn, string(data)); err != nil {
+		emitter.conn.Close()
+		emitter.conn = nil
+		return err
+	}
+
+	return nil
+}
+
+// newEmitter builds the Emitter selected by --output. esIndex is only
+// used by the esbulk sink. aggregateEnabled selects the fingerprint
+// summary column set for the csv sink instead of the per-query one.
+func newEmitter(output string, esIndex string, aggregateEnabled bool) (Emitter, error) {
+	switch {
+	case output == "" || output == "ndjson":
+		return NewNDJSONEmitter(os.Stdout), nil
+	case output == "csv":
+		if aggregateEnabled {
+			return NewCSVEmitterWithColumns(os.Stdout, aggregateCSVColumns()), nil
+		}
+
+		return NewCSVEmitter(os.Stdout), nil
+	case output == "esbulk":
+		return NewESBulkEmitter(os.Stdout, esIndex), nil
+	case strings.HasPrefix(output, "tcp://"):
+		return NewTCPEmitter(strings.TrimPrefix(output, "tcp://")), nil
+	default:
+		return nil, fmt.Errorf("unsupported --output sink: %s", output)
+	}
+}
+
 func unmarshal(line string, record Record) error {
 	if !strings.HasPrefix(line, "# ") {
 
@@ -293,7 +756,7 @@ func match(data, key string) (string, bool) {
 func parse(raw, key, rule string) (interface{}, error) {
 	switch rule {
 	case "datetime":
-		return time.Parse("2006-01-02T15:04:05.000000Z", raw)
+		return parseDatetime(raw)
 	case "time":
 		return time.ParseDuration(raw + "s")
 	case "string":
@@ -394,3 +857,271 @@ func GetTablePtrsName(te sqlparser.TableExprs) string {
 	// fmt.Println(len(namelist))
 	return strings.Join(keys, ",")
 }
+
+// Advice is a single finding surfaced by the --advise query-advisor
+// rules against a parsed statement.
+type Advice struct {
+	ID       string `json:"id"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+var (
+	// adviseEnabled gates the advisor subsystem behind --advise.
+	adviseEnabled bool
+
+	// adviseRules restricts advise() to the given rule IDs when set via
+	// --advise-rules. A nil map means every rule runs.
+	adviseRules map[string]bool
+
+	// adviseInThreshold is the IN (...) list length above which SEL.007
+	// fires. Overridden by --advise-in-threshold.
+	adviseInThreshold = 100
+)
+
+// advise inspects a parsed statement with a single sqlparser.Walk pass
+// and returns every advisor finding, filtered by adviseRules and sorted
+// by rule ID for stable output.
+func advise(stmt sqlparser.Statement) []Advice {
+	var findings []Advice
+
+	switch s := stmt.(type) {
+	case *sqlparser.Select:
+		findings = append(findings, adviseSelectWithoutWhere(s)...)
+		findings = append(findings, adviseSelectStar(s)...)
+		findings = append(findings, adviseOrderByRand(s)...)
+	case *sqlparser.Update:
+		findings = append(findings, adviseUpdateWithoutWhere(s)...)
+	case *sqlparser.Delete:
+		findings = append(findings, adviseDeleteWithoutWhere(s)...)
+	}
+
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		switch n := node.(type) {
+		case *sqlparser.ComparisonExpr:
+			findings = append(findings, adviseComparisonExpr(n)...)
+		case *sqlparser.OrExpr:
+			findings = append(findings, adviseOrExpr(n)...)
+		}
+
+		return true, nil
+	}, stmt)
+
+	return filterAdvice(findings)
+}
+
+func filterAdvice(findings []Advice) []Advice {
+	if adviseRules == nil {
+		sort.Slice(findings, func(i, j int) bool { return findings[i].ID < findings[j].ID })
+		return findings
+	}
+
+	filtered := findings[:0]
+	for _, finding := range findings {
+		if adviseRules[finding.ID] {
+			filtered = append(filtered, finding)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].ID < filtered[j].ID })
+	return filtered
+}
+
+// aggregateFuncs are function names whose presence in a SELECT's
+// projection means the statement summarizes rows rather than fetching
+// them individually, so a missing WHERE clause is expected.
+var aggregateFuncs = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true,
+}
+
+func hasAggregate(exprs sqlparser.SelectExprs) bool {
+	for _, expr := range exprs {
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+
+		if fn, ok := aliased.Expr.(*sqlparser.FuncExpr); ok {
+			if aggregateFuncs[strings.ToLower(fn.Name.String())] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func adviseSelectWithoutWhere(sel *sqlparser.Select) []Advice {
+	if sel.Where != nil || len(sel.GroupBy) > 0 || hasAggregate(sel.SelectExprs) {
+		return nil
+	}
+
+	return []Advice{{
+		ID:       "SEL.001",
+		Severity: "warning",
+		Message:  "SELECT without a WHERE clause scans the whole table",
+	}}
+}
+
+func adviseSelectStar(sel *sqlparser.Select) []Advice {
+	for _, expr := range sel.SelectExprs {
+		if _, ok := expr.(*sqlparser.StarExpr); ok {
+			return []Advice{{
+				ID:       "SEL.002",
+				Severity: "info",
+				Message:  "SELECT * fetches every column; poke doesn't know the table's column count so this always fires",
+			}}
+		}
+	}
+
+	return nil
+}
+
+func adviseOrderByRand(sel *sqlparser.Select) []Advice {
+	for _, order := range sel.OrderBy {
+		if fn, ok := order.Expr.(*sqlparser.FuncExpr); ok && strings.EqualFold(fn.Name.String(), "rand") {
+			return []Advice{{
+				ID:       "SEL.005",
+				Severity: "warning",
+				Message:  "ORDER BY RAND() forces a full scan and filesort to shuffle rows",
+			}}
+		}
+	}
+
+	return nil
+}
+
+func adviseUpdateWithoutWhere(upd *sqlparser.Update) []Advice {
+	if upd.Where != nil {
+		return nil
+	}
+
+	return []Advice{{
+		ID:       "UPD.001",
+		Severity: "critical",
+		Message:  "UPDATE without a WHERE clause modifies every row in the table",
+	}}
+}
+
+func adviseDeleteWithoutWhere(del *sqlparser.Delete) []Advice {
+	if del.Where != nil {
+		return nil
+	}
+
+	return []Advice{{
+		ID:       "UPD.002",
+		Severity: "critical",
+		Message:  "DELETE without a WHERE clause removes every row in the table",
+	}}
+}
+
+func adviseComparisonExpr(cmp *sqlparser.ComparisonExpr) []Advice {
+	var findings []Advice
+
+	switch cmp.Operator {
+	case sqlparser.LikeStr, sqlparser.NotLikeStr:
+		if val, ok := cmp.Right.(*sqlparser.SQLVal); ok && val.Type == sqlparser.StrVal {
+			if strings.HasPrefix(string(val.Val), "%") {
+				findings = append(findings, Advice{
+					ID:       "SEL.003",
+					Severity: "warning",
+					Message:  "LIKE pattern starts with a wildcard and can't use an index",
+				})
+			}
+		}
+
+	case sqlparser.InStr, sqlparser.NotInStr:
+		if tuple, ok := cmp.Right.(sqlparser.ValTuple); ok && len(tuple) > adviseInThreshold {
+			findings = append(findings, Advice{
+				ID:       "SEL.007",
+				Severity: "info",
+				Message: fmt.Sprintf(
+					"IN (...) list has %d values, above the %d threshold",
+					len(tuple), adviseInThreshold,
+				),
+			})
+		}
+	}
+
+	if isImplicitTypeConversion(cmp) {
+		findings = append(findings, Advice{
+			ID:       "SEL.006",
+			Severity: "warning",
+			Message:  "comparison mixes a string-like column with a bare numeric literal, forcing an implicit type conversion",
+		})
+	}
+
+	return findings
+}
+
+// stringIdentifierHints are column-name substrings that conventionally
+// hold digits stored as text (leading zeros, formatting, etc). poke has
+// no schema, so this is a heuristic, not a guarantee.
+var stringIdentifierHints = []string{"zip", "phone", "sku", "code", "uuid", "guid"}
+
+func isImplicitTypeConversion(cmp *sqlparser.ComparisonExpr) bool {
+	col, ok := colNameAndIntVal(cmp.Left, cmp.Right)
+	if !ok {
+		col, ok = colNameAndIntVal(cmp.Right, cmp.Left)
+	}
+	if !ok {
+		return false
+	}
+
+	name := strings.ToLower(col.Name.String())
+	for _, hint := range stringIdentifierHints {
+		if strings.Contains(name, hint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// colNameAndIntVal matches left as a ColName and right as an integer
+// literal. isImplicitTypeConversion calls it both ways round, since
+// `zip = 12345` and `12345 = zip` are equally implicit conversions.
+func colNameAndIntVal(left, right sqlparser.Expr) (*sqlparser.ColName, bool) {
+	col, ok := left.(*sqlparser.ColName)
+	if !ok {
+		return nil, false
+	}
+
+	val, ok := right.(*sqlparser.SQLVal)
+	if !ok || val.Type != sqlparser.IntVal {
+		return nil, false
+	}
+
+	return col, true
+}
+
+func adviseOrExpr(or *sqlparser.OrExpr) []Advice {
+	left := columnNamesIn(or.Left)
+	right := columnNamesIn(or.Right)
+
+	for name := range left {
+		if !right[name] {
+			return []Advice{{
+				ID:       "SEL.004",
+				Severity: "info",
+				Message:  "OR combines predicates on different columns, which can defeat a single-column index",
+			}}
+		}
+	}
+
+	return nil
+}
+
+func columnNamesIn(expr sqlparser.Expr) map[string]bool {
+	names := map[string]bool{}
+
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if col, ok := node.(*sqlparser.ColName); ok {
+			names[col.Name.String()] = true
+		}
+
+		return true, nil
+	}, expr)
+
+	return names
+}
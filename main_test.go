@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// TestTailerReopenIfRotatedNoRotation checks that reopenIfRotated is a
+// no-op while the file at t.path is still the one t.file has open and
+// hasn't shrunk out from under the current offset.
+func TestTailerReopenIfRotatedNoRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "slow.log")
+
+	if err := os.WriteFile(path, []byte("first\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("first\nsecond\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tail := &tailer{path: path, file: file}
+
+	reader, err := tail.reopenIfRotated()
+	if err != nil {
+		t.Fatalf("reopenIfRotated: %s", err)
+	}
+	if reader != nil {
+		t.Fatal("expected no reopen when the file wasn't rotated")
+	}
+}
+
+// TestTailerReopenIfRotatedRename checks the rename-and-replace rotation
+// pattern: the old inode is moved aside and a fresh file takes its
+// place at path.
+func TestTailerReopenIfRotatedRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "slow.log")
+
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := os.Rename(path, filepath.Join(dir, "slow.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tail := &tailer{path: path, file: file}
+
+	reader, err := tail.reopenIfRotated()
+	if err != nil {
+		t.Fatalf("reopenIfRotated: %s", err)
+	}
+	if reader == nil {
+		t.Fatal("expected a reopen after the file was renamed away")
+	}
+
+	line, _, err := reader.ReadLine()
+	if err != nil {
+		t.Fatalf("ReadLine: %s", err)
+	}
+	if string(line) != "new" {
+		t.Errorf("line = %q, want %q", line, "new")
+	}
+}
+
+// TestTailerReopenIfRotatedTruncate checks the truncate-in-place
+// rotation pattern some log servers use instead of rename: the inode is
+// unchanged, but its size drops below the reader's current offset.
+func TestTailerReopenIfRotatedTruncate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "slow.log")
+
+	if err := os.WriteFile(path, []byte("first\nsecond\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tail := &tailer{path: path, file: file}
+
+	reader, err := tail.reopenIfRotated()
+	if err != nil {
+		t.Fatalf("reopenIfRotated: %s", err)
+	}
+	if reader == nil {
+		t.Fatal("expected a reopen after the file was truncated in place")
+	}
+}
+
+// TestCSVEmitterWritesHeaderThenRows checks that the header is written
+// once, ahead of the first row, and that a []string cell (as produced by
+// --aggregate's tables field) is rendered as a delimited list.
+func TestCSVEmitterWritesHeaderThenRows(t *testing.T) {
+	var buf bytes.Buffer
+	emitter := NewCSVEmitterWithColumns(&buf, []string{"a", "b"})
+
+	if err := emitter.Emit(Record{"a": "1", "b": []string{"x", "y"}}); err != nil {
+		t.Fatalf("Emit: %s", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll: %s", err)
+	}
+
+	want := [][]string{{"a", "b"}, {"1", "x;y"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+// TestESBulkEmitterWritesActionAndDocument checks the two-line
+// index-action/document shape _bulk expects, and that the index name is
+// suffixed with the record's time_start date.
+func TestESBulkEmitterWritesActionAndDocument(t *testing.T) {
+	var buf bytes.Buffer
+	emitter := NewESBulkEmitter(&buf, "poke")
+
+	record := Record{
+		"time_start": "2024-01-02T03:04:05.000000Z",
+		"query":      "select 1",
+	}
+	if err := emitter.Emit(record); err != nil {
+		t.Fatalf("Emit: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2 (action + document)", len(lines))
+	}
+
+	var action map[string]map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &action); err != nil {
+		t.Fatalf("unmarshal action line: %s", err)
+	}
+	if got := action["index"]["_index"]; got != "poke-2024.01.02" {
+		t.Errorf("_index = %q, want %q", got, "poke-2024.01.02")
+	}
+
+	var document Record
+	if err := json.Unmarshal([]byte(lines[1]), &document); err != nil {
+		t.Fatalf("unmarshal document line: %s", err)
+	}
+	if document["query"] != "select 1" {
+		t.Errorf("document query = %v, want %q", document["query"], "select 1")
+	}
+}
+
+// TestTCPEmitterWritesNDJSONToConnection checks that Emit dials the
+// configured address lazily and writes one NDJSON line per record.
+func TestTCPEmitterWritesNDJSONToConnection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	emitter := NewTCPEmitter(listener.Addr().String())
+	if err := emitter.Emit(Record{"query": "select 1"}); err != nil {
+		t.Fatalf("Emit: %s", err)
+	}
+
+	select {
+	case line := <-received:
+		var record Record
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("unmarshal: %s", err)
+		}
+		if record["query"] != "select 1" {
+			t.Errorf("query = %v, want %q", record["query"], "select 1")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for TCPEmitter to write")
+	}
+}
+
+// findingIDs parses query with sqlparser, runs advise() on the result
+// and returns the sorted rule IDs it found.
+func findingIDs(t *testing.T, query string) []string {
+	t.Helper()
+
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		t.Fatalf("Parse(%q): %s", query, err)
+	}
+
+	var ids []string
+	for _, finding := range advise(stmt) {
+		ids = append(ids, finding.ID)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestAdviseRules(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"select without where", "select * from users", []string{"SEL.001", "SEL.002"}},
+		{"select with where", "select id from users where id = 1", nil},
+		{"select count without where is not flagged", "select count(*) from users", nil},
+		{"select star", "select * from users where id = 1", []string{"SEL.002"}},
+		{"leading wildcard like", "select id from users where name like '%bob'", []string{"SEL.003"}},
+		{"trailing wildcard like is not flagged", "select id from users where name like 'bob%'", nil},
+		{"or across columns", "select id from users where name = 'a' or email = 'b'", []string{"SEL.004"}},
+		{"or on same column", "select id from users where id = 1 or id = 2", nil},
+		{"order by rand", "select id from users order by rand()", []string{"SEL.001", "SEL.005"}},
+		{"implicit conversion, column left", "select id from users where zip = 12345", []string{"SEL.006"}},
+		{"implicit conversion, column right", "select id from users where 12345 = zip", []string{"SEL.006"}},
+		{"matching types is not flagged", "select id from users where zip = '12345'", nil},
+		{"update without where", "update users set name = 'a'", []string{"UPD.001"}},
+		{"update with where", "update users set name = 'a' where id = 1", nil},
+		{"delete without where", "delete from users", []string{"UPD.002"}},
+		{"delete with where", "delete from users where id = 1", nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := findingIDs(t, c.query)
+			want := append([]string(nil), c.want...)
+			sort.Strings(want)
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("advise(%q) IDs = %v, want %v", c.query, got, want)
+			}
+		})
+	}
+}
+
+// TestAdviseInThresholdRule checks SEL.007, which depends on the package
+// level adviseInThreshold rather than being derivable from the query
+// alone, so it's kept separate from the table-driven cases above.
+func TestAdviseInThresholdRule(t *testing.T) {
+	previous := adviseInThreshold
+	adviseInThreshold = 3
+	defer func() { adviseInThreshold = previous }()
+
+	got := findingIDs(t, "select id from users where id in (1, 2, 3, 4, 5)")
+	if want := []string{"SEL.007"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IDs = %v, want %v", got, want)
+	}
+
+	got = findingIDs(t, "select id from users where id in (1, 2)")
+	if len(got) != 0 {
+		t.Errorf("IDs = %v, want none below the threshold", got)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+// defaultSchema prefixes table names that a query left unqualified, so
+// downstream consumers always see a db.table pair. Set via
+// --default-schema; empty means table names are left unqualified.
+var defaultSchema string
+
+// joinPredicate is one equality (or comparison) tying two tables
+// together in a JOIN ... ON clause.
+type joinPredicate struct {
+	Left     string `json:"left"`
+	Operator string `json:"operator"`
+	Right    string `json:"right"`
+}
+
+// buildSchemaMeta walks stmt's table and column references to describe
+// which schema-qualified tables and columns it touches, split out by
+// clause, plus the join predicates linking those tables. It's attached
+// to each record as record["schema_meta"] so a downstream consumer can
+// answer "which queries touch column X of table Y" without re-parsing
+// the query.
+func buildSchemaMeta(stmt sqlparser.Statement) Record {
+	tableExprs := tableExprsOf(stmt)
+	aliases, tables := resolveTableAliases(tableExprs)
+
+	columns := map[string][]string{
+		"select":   {},
+		"where":    {},
+		"group_by": {},
+		"order_by": {},
+	}
+
+	switch s := stmt.(type) {
+	case *sqlparser.Select:
+		columns["select"] = columnsIn(s.SelectExprs, aliases)
+		if s.Where != nil {
+			columns["where"] = columnsIn(s.Where.Expr, aliases)
+		}
+		columns["group_by"] = columnsIn(s.GroupBy, aliases)
+		columns["order_by"] = columnsIn(s.OrderBy, aliases)
+	case *sqlparser.Update:
+		if s.Where != nil {
+			columns["where"] = columnsIn(s.Where.Expr, aliases)
+		}
+	case *sqlparser.Delete:
+		if s.Where != nil {
+			columns["where"] = columnsIn(s.Where.Expr, aliases)
+		}
+	}
+
+	return Record{
+		"tables":  tables,
+		"columns": columns,
+		"joins":   joinPredicatesOf(tableExprs, aliases),
+	}
+}
+
+// tableExprsOf returns the table expressions a statement selects,
+// updates or deletes from, so the rest of the schema walker can treat
+// every statement kind uniformly.
+func tableExprsOf(stmt sqlparser.Statement) sqlparser.TableExprs {
+	switch s := stmt.(type) {
+	case *sqlparser.Select:
+		return s.From
+	case *sqlparser.Update:
+		return s.TableExprs
+	case *sqlparser.Delete:
+		return s.TableExprs
+	case *sqlparser.Insert:
+		return sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: s.Table}}
+	}
+
+	return nil
+}
+
+// resolveTableAliases walks tableExprs and returns a map from every
+// alias (or bare table name, when unaliased) to its schema-qualified
+// name, along with the sorted set of qualified names touched.
+func resolveTableAliases(tableExprs sqlparser.TableExprs) (map[string]string, []string) {
+	aliases := map[string]string{}
+	tableSet := map[string]bool{}
+
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		aliased, ok := node.(*sqlparser.AliasedTableExpr)
+		if !ok {
+			return true, nil
+		}
+
+		tableName, ok := aliased.Expr.(sqlparser.TableName)
+		if !ok {
+			return true, nil
+		}
+
+		qualified := qualifiedTableName(tableName)
+		tableSet[qualified] = true
+
+		key := strings.ToLower(tableName.Name.String())
+		if !aliased.As.IsEmpty() {
+			key = strings.ToLower(aliased.As.String())
+		}
+
+		aliases[key] = qualified
+
+		return true, nil
+	}, tableExprs)
+
+	tables := make([]string, 0, len(tableSet))
+	for table := range tableSet {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	return aliases, tables
+}
+
+func qualifiedTableName(tableName sqlparser.TableName) string {
+	if !tableName.Qualifier.IsEmpty() {
+		return tableName.Qualifier.String() + "." + tableName.Name.String()
+	}
+
+	if defaultSchema == "" {
+		return tableName.Name.String()
+	}
+
+	return defaultSchema + "." + tableName.Name.String()
+}
+
+// columnsIn returns the sorted set of distinct columns referenced under
+// node, qualified against aliases where a table qualifier is present.
+func columnsIn(node sqlparser.SQLNode, aliases map[string]string) []string {
+	seen := map[string]bool{}
+
+	sqlparser.Walk(func(n sqlparser.SQLNode) (bool, error) {
+		if col, ok := n.(*sqlparser.ColName); ok {
+			seen[qualifiedColumnName(col, aliases)] = true
+		}
+
+		return true, nil
+	}, node)
+
+	columns := make([]string, 0, len(seen))
+	for column := range seen {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	return columns
+}
+
+func qualifiedColumnName(col *sqlparser.ColName, aliases map[string]string) string {
+	if col.Qualifier.IsEmpty() {
+		return col.Name.String()
+	}
+
+	if table, ok := aliases[strings.ToLower(col.Qualifier.Name.String())]; ok {
+		return table + "." + col.Name.String()
+	}
+
+	return col.Qualifier.Name.String() + "." + col.Name.String()
+}
+
+// joinPredicatesOf finds every JOIN ... ON clause under tableExprs and
+// extracts its column-to-column comparisons.
+func joinPredicatesOf(tableExprs sqlparser.TableExprs, aliases map[string]string) []joinPredicate {
+	var predicates []joinPredicate
+
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		join, ok := node.(*sqlparser.JoinTableExpr)
+		if !ok || join.Condition.On == nil {
+			return true, nil
+		}
+
+		sqlparser.Walk(func(n sqlparser.SQLNode) (bool, error) {
+			cmp, ok := n.(*sqlparser.ComparisonExpr)
+			if !ok {
+				return true, nil
+			}
+
+			left, leftOK := cmp.Left.(*sqlparser.ColName)
+			right, rightOK := cmp.Right.(*sqlparser.ColName)
+			if leftOK && rightOK {
+				predicates = append(predicates, joinPredicate{
+					Left:     qualifiedColumnName(left, aliases),
+					Operator: cmp.Operator,
+					Right:    qualifiedColumnName(right, aliases),
+				})
+			}
+
+			return true, nil
+		}, join.Condition.On)
+
+		return true, nil
+	}, tableExprs)
+
+	return predicates
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xwb1989/sqlparser"
+)
+
+func parseStatement(t *testing.T, query string) sqlparser.Statement {
+	t.Helper()
+
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		t.Fatalf("Parse(%q): %s", query, err)
+	}
+
+	return stmt
+}
+
+// TestResolveTableAliasesUnqualified checks that an unaliased table is
+// keyed under its own (lowercased) name and, with no --default-schema
+// set, is left unqualified in the returned table list.
+func TestResolveTableAliasesUnqualified(t *testing.T) {
+	stmt := parseStatement(t, "select id from Users")
+
+	aliases, tables := resolveTableAliases(tableExprsOf(stmt))
+
+	if aliases["users"] != "Users" {
+		t.Errorf("aliases[users] = %q, want %q", aliases["users"], "Users")
+	}
+	if want := []string{"Users"}; !reflect.DeepEqual(tables, want) {
+		t.Errorf("tables = %v, want %v", tables, want)
+	}
+}
+
+// TestResolveTableAliasesQualified checks that each alias in an aliased
+// join maps to its table's name, keeping an explicit schema qualifier.
+func TestResolveTableAliasesQualified(t *testing.T) {
+	defaultSchema = ""
+	defer func() { defaultSchema = "" }()
+
+	stmt := parseStatement(t, "select u.id from shop.users u join orders o on u.id = o.user_id")
+
+	aliases, tables := resolveTableAliases(tableExprsOf(stmt))
+
+	if aliases["u"] != "shop.users" {
+		t.Errorf("aliases[u] = %q, want %q", aliases["u"], "shop.users")
+	}
+	if aliases["o"] != "orders" {
+		t.Errorf("aliases[o] = %q, want %q", aliases["o"], "orders")
+	}
+	if want := []string{"orders", "shop.users"}; !reflect.DeepEqual(tables, want) {
+		t.Errorf("tables = %v, want %v", tables, want)
+	}
+}
+
+// TestResolveTableAliasesDefaultSchema checks that an unqualified table
+// is prefixed with --default-schema when one is configured.
+func TestResolveTableAliasesDefaultSchema(t *testing.T) {
+	defaultSchema = "shop"
+	defer func() { defaultSchema = "" }()
+
+	stmt := parseStatement(t, "select id from users")
+
+	_, tables := resolveTableAliases(tableExprsOf(stmt))
+
+	if want := []string{"shop.users"}; !reflect.DeepEqual(tables, want) {
+		t.Errorf("tables = %v, want %v", tables, want)
+	}
+}
+
+// TestQualifiedColumnNameResolvesAlias checks that a column qualified by
+// a table alias is rewritten to its underlying schema-qualified table.
+func TestQualifiedColumnNameResolvesAlias(t *testing.T) {
+	aliases := map[string]string{"u": "shop.users"}
+
+	stmt := parseStatement(t, "select u.id from shop.users u")
+	sel := stmt.(*sqlparser.Select)
+	col := sel.SelectExprs[0].(*sqlparser.AliasedExpr).Expr.(*sqlparser.ColName)
+
+	if got := qualifiedColumnName(col, aliases); got != "shop.users.id" {
+		t.Errorf("qualifiedColumnName = %q, want %q", got, "shop.users.id")
+	}
+}
+
+// TestQualifiedColumnNameUnqualified checks that a bare column name (no
+// table qualifier at all) passes through unchanged.
+func TestQualifiedColumnNameUnqualified(t *testing.T) {
+	stmt := parseStatement(t, "select id from users")
+	sel := stmt.(*sqlparser.Select)
+	col := sel.SelectExprs[0].(*sqlparser.AliasedExpr).Expr.(*sqlparser.ColName)
+
+	if got := qualifiedColumnName(col, map[string]string{}); got != "id" {
+		t.Errorf("qualifiedColumnName = %q, want %q", got, "id")
+	}
+}
+
+// TestBuildSchemaMetaSelect exercises buildSchemaMeta end-to-end against
+// a join with a WHERE, GROUP BY and ORDER BY clause, checking that
+// columns are bucketed by clause and join predicates are extracted.
+func TestBuildSchemaMetaSelect(t *testing.T) {
+	defaultSchema = ""
+	defer func() { defaultSchema = "" }()
+
+	stmt := parseStatement(t, `
+		select u.id, count(o.id)
+		from users u join orders o on u.id = o.user_id
+		where u.active = 1
+		group by u.id
+		order by u.id
+	`)
+
+	meta := buildSchemaMeta(stmt)
+
+	if want := []string{"orders", "users"}; !reflect.DeepEqual(meta["tables"], want) {
+		t.Errorf("tables = %v, want %v", meta["tables"], want)
+	}
+
+	columns := meta["columns"].(map[string][]string)
+	if want := []string{"orders.id", "users.id"}; !reflect.DeepEqual(columns["select"], want) {
+		t.Errorf("select columns = %v, want %v", columns["select"], want)
+	}
+	if want := []string{"users.active"}; !reflect.DeepEqual(columns["where"], want) {
+		t.Errorf("where columns = %v, want %v", columns["where"], want)
+	}
+	if want := []string{"users.id"}; !reflect.DeepEqual(columns["group_by"], want) {
+		t.Errorf("group_by columns = %v, want %v", columns["group_by"], want)
+	}
+	if want := []string{"users.id"}; !reflect.DeepEqual(columns["order_by"], want) {
+		t.Errorf("order_by columns = %v, want %v", columns["order_by"], want)
+	}
+
+	joins := meta["joins"].([]joinPredicate)
+	want := []joinPredicate{{Left: "users.id", Operator: "=", Right: "orders.user_id"}}
+	if !reflect.DeepEqual(joins, want) {
+		t.Errorf("joins = %v, want %v", joins, want)
+	}
+}
+
+// TestBuildSchemaMetaUpdate checks that non-SELECT statements still walk
+// their WHERE clause and tableExprsOf resolves Update's single table.
+func TestBuildSchemaMetaUpdate(t *testing.T) {
+	stmt := parseStatement(t, "update users set name = 'a' where id = 1")
+
+	meta := buildSchemaMeta(stmt)
+
+	if want := []string{"users"}; !reflect.DeepEqual(meta["tables"], want) {
+		t.Errorf("tables = %v, want %v", meta["tables"], want)
+	}
+
+	columns := meta["columns"].(map[string][]string)
+	if want := []string{"id"}; !reflect.DeepEqual(columns["where"], want) {
+		t.Errorf("where columns = %v, want %v", columns["where"], want)
+	}
+}